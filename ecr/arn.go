@@ -0,0 +1,84 @@
+package ecr
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ECRRef is a normalized reference to an ECR repository, optionally pinned
+// to a specific image via Tag and/or Digest.
+type ECRRef struct {
+	Account string
+	Region  string
+	Repo    string
+	Tag     string
+	Digest  string
+}
+
+// arnPattern matches a standard ECR repository ARN, e.g.
+// "arn:aws:ecr:us-east-1:123456789012:repository/my-repo".
+var arnPattern = regexp.MustCompile(`^arn:aws[a-zA-Z-]*:ecr:([a-z0-9-]+):(\d+):repository/(.+)$`)
+
+// uriPattern matches an ECR registry URI, e.g.
+// "123456789012.dkr.ecr.us-east-1.amazonaws.com/my-repo".
+var uriPattern = regexp.MustCompile(`^(\d+)\.dkr\.ecr\.([a-z0-9-]+)\.amazonaws\.com/(.+)$`)
+
+// ParseECRRef parses ref as either a standard ECR repository ARN or an ECR
+// registry URI of the form "<account>.dkr.ecr.<region>.amazonaws.com/<repo>[:tag]".
+// regionOverride, if non-empty, replaces the region parsed from ref; this
+// allows callers to target regions not yet known to the vendored
+// aws-sdk-go, such as a newly launched region, before ref's own region
+// segment can be resolved by the SDK.
+func ParseECRRef(ref string, regionOverride string) (ECRRef, error) {
+	repo, tag, digest := splitRepoReference(ref)
+
+	var account, region string
+	switch {
+	case arnPattern.MatchString(ref):
+		m := arnPattern.FindStringSubmatch(ref)
+		region, account, repo = m[1], m[2], m[3]
+		repo, tag, digest = splitRepoReference(repo)
+	case uriPattern.MatchString(repo):
+		m := uriPattern.FindStringSubmatch(repo)
+		account, region, repo = m[1], m[2], m[3]
+	default:
+		return ECRRef{}, errors.Errorf("%q is not a recognized ECR ARN or registry URI", ref)
+	}
+
+	if regionOverride != "" {
+		region = regionOverride
+	}
+
+	return ECRRef{
+		Account: account,
+		Region:  region,
+		Repo:    repo,
+		Tag:     tag,
+		Digest:  digest,
+	}, nil
+}
+
+// splitRepoReference splits a "repo[:tag][@digest]" suffix off of ref,
+// leaving the unqualified portion in repo.
+func splitRepoReference(ref string) (repo, tag, digest string) {
+	repo = ref
+	if i := strings.Index(repo, "@"); i != -1 {
+		repo, digest = repo[:i], repo[i+1:]
+	}
+	if i := strings.LastIndex(repo, ":"); i != -1 && !strings.Contains(repo[i:], "/") {
+		repo, tag = repo[:i], repo[i+1:]
+	}
+	return repo, tag, digest
+}
+
+// NameAccountRegionFromARN resolves repoName, accountID and region from an
+// ECR repository ARN or registry URI, as used throughout this package.
+func NameAccountRegionFromARN(arn string) (repoName string, accountID string, region string, err error) {
+	ref, err := ParseECRRef(arn, "")
+	if err != nil {
+		return "", "", "", err
+	}
+	return ref.Repo, ref.Account, ref.Region, nil
+}
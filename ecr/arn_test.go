@@ -0,0 +1,82 @@
+package ecr
+
+import "testing"
+
+func TestParseECRRef(t *testing.T) {
+	cases := []struct {
+		name           string
+		ref            string
+		regionOverride string
+		want           ECRRef
+		wantErr        bool
+	}{
+		{
+			name: "standard ARN",
+			ref:  "arn:aws:ecr:us-east-1:123456789012:repository/my-repo",
+			want: ECRRef{Account: "123456789012", Region: "us-east-1", Repo: "my-repo"},
+		},
+		{
+			name: "registry URI",
+			ref:  "123456789012.dkr.ecr.us-east-1.amazonaws.com/my-repo",
+			want: ECRRef{Account: "123456789012", Region: "us-east-1", Repo: "my-repo"},
+		},
+		{
+			name: "registry URI with tag",
+			ref:  "123456789012.dkr.ecr.us-east-1.amazonaws.com/my-repo:v1",
+			want: ECRRef{Account: "123456789012", Region: "us-east-1", Repo: "my-repo", Tag: "v1"},
+		},
+		{
+			name:           "region override wins over parsed region",
+			ref:            "123456789012.dkr.ecr.us-east-1.amazonaws.com/my-repo",
+			regionOverride: "il-central-1",
+			want:           ECRRef{Account: "123456789012", Region: "il-central-1", Repo: "my-repo"},
+		},
+		{
+			name:    "unrecognized reference",
+			ref:     "gcr.io/proj/app",
+			wantErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := ParseECRRef(c.ref, c.regionOverride)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("expected error for ref %q", c.ref)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != c.want {
+				t.Errorf("ParseECRRef(%q) = %+v, want %+v", c.ref, got, c.want)
+			}
+		})
+	}
+}
+
+func TestSplitRepoReference(t *testing.T) {
+	cases := []struct {
+		ref        string
+		wantRepo   string
+		wantTag    string
+		wantDigest string
+	}{
+		{ref: "my-repo", wantRepo: "my-repo"},
+		{ref: "my-repo:v1", wantRepo: "my-repo", wantTag: "v1"},
+		{ref: "my-repo@sha256:abcd1234", wantRepo: "my-repo", wantDigest: "sha256:abcd1234"},
+		{ref: "path/my-repo:v1", wantRepo: "path/my-repo", wantTag: "v1"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.ref, func(t *testing.T) {
+			repo, tag, digest := splitRepoReference(c.ref)
+			if repo != c.wantRepo || tag != c.wantTag || digest != c.wantDigest {
+				t.Errorf("splitRepoReference(%q) = (%q, %q, %q), want (%q, %q, %q)",
+					c.ref, repo, tag, digest, c.wantRepo, c.wantTag, c.wantDigest)
+			}
+		})
+	}
+}
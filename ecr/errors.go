@@ -0,0 +1,14 @@
+package ecr
+
+import "fmt"
+
+// ErrImmutableRepository is returned by Add when the target ECR repository
+// has IMMUTABLE tag mutability configured, so the requested tag cannot be
+// moved onto a new image digest via PutImage.
+type ErrImmutableRepository struct {
+	Repository string
+}
+
+func (e *ErrImmutableRepository) Error() string {
+	return fmt.Sprintf("repository %s has immutable tag mutability; tags cannot be re-pointed", e.Repository)
+}
@@ -0,0 +1,131 @@
+package ecr
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/pkg/errors"
+)
+
+// ImageInfo describes a single ECR image, as returned by List.
+type ImageInfo struct {
+	Tags     []string
+	Digest   string
+	PushedAt time.Time
+}
+
+// Filter narrows the set of images returned by List.
+type Filter struct {
+	// IncludeTag, if set, keeps only images with at least one tag matching
+	// this regexp.
+	IncludeTag *regexp.Regexp
+	// ExcludeTag, if set, drops images with at least one tag matching this
+	// regexp.
+	ExcludeTag *regexp.Regexp
+	// MaxAge, if non-zero, drops images pushed before time.Now().Add(-MaxAge).
+	MaxAge time.Duration
+}
+
+func (f Filter) matches(detail *ecr.ImageDetail) bool {
+	if f.MaxAge != 0 && aws.TimeValue(detail.ImagePushedAt).Before(time.Now().Add(-f.MaxAge)) {
+		return false
+	}
+
+	tags := aws.StringValueSlice(detail.ImageTags)
+
+	if f.IncludeTag != nil {
+		var matched bool
+		for _, tag := range tags {
+			if f.IncludeTag.MatchString(tag) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	if f.ExcludeTag != nil {
+		for _, tag := range tags {
+			if f.ExcludeTag.MatchString(tag) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// Lister provides the capability of answering "what images exist" without
+// the caller already knowing the exact version tag, complementing Tagger
+// (whose Get requires the version up front).
+type Lister interface {
+	// Latest returns the most-recently pushed image matching filter.
+	Latest(ecrARN string, filter Filter) (tag string, digest string, pushedAt time.Time, err error)
+	// List returns all images matching filter, sorted by push time descending.
+	List(ecrARN string, filter Filter) ([]ImageInfo, error)
+}
+
+func (t *tagger) Latest(ecrARN string, filter Filter) (string, string, time.Time, error) {
+	images, err := t.List(ecrARN, filter)
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+	if len(images) == 0 {
+		return "", "", time.Time{}, errors.New("no images matched the given filter")
+	}
+
+	latest := images[0]
+	var tag string
+	if len(latest.Tags) > 0 {
+		tag = latest.Tags[0]
+	}
+
+	return tag, latest.Digest, latest.PushedAt, nil
+}
+
+func (t *tagger) List(ecrARN string, filter Filter) ([]ImageInfo, error) {
+	repoName, accountID, region, err := t.resolveRef(ecrARN)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read ECR repository ARN")
+	}
+
+	ecrClient := t.clientFor(region)
+
+	var details []*ecr.ImageDetail
+	err = ecrClient.DescribeImagesPages(&ecr.DescribeImagesInput{
+		RegistryId:     aws.String(accountID),
+		RepositoryName: aws.String(repoName),
+	}, func(page *ecr.DescribeImagesOutput, lastPage bool) bool {
+		for _, detail := range page.ImageDetails {
+			if filter.matches(detail) {
+				details = append(details, detail)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		t.stats.IncCount(fmt.Sprintf("ecr.descimg.%s.failure", repoName))
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to describe images of repository %s", repoName))
+	}
+
+	sort.Slice(details, func(i, j int) bool {
+		return aws.TimeValue(details[i].ImagePushedAt).After(aws.TimeValue(details[j].ImagePushedAt))
+	})
+
+	images := make([]ImageInfo, 0, len(details))
+	for _, detail := range details {
+		images = append(images, ImageInfo{
+			Tags:     aws.StringValueSlice(detail.ImageTags),
+			Digest:   aws.StringValue(detail.ImageDigest),
+			PushedAt: aws.TimeValue(detail.ImagePushedAt),
+		})
+	}
+
+	return images, nil
+}
@@ -0,0 +1,73 @@
+package ecr
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecr"
+)
+
+func mkDetail(tags []string, pushedAt time.Time) *ecr.ImageDetail {
+	return &ecr.ImageDetail{
+		ImageTags:     aws.StringSlice(tags),
+		ImagePushedAt: aws.Time(pushedAt),
+	}
+}
+
+func TestFilterMatches(t *testing.T) {
+	now := time.Now()
+
+	cases := []struct {
+		name   string
+		filter Filter
+		detail *ecr.ImageDetail
+		want   bool
+	}{
+		{
+			name:   "no filter matches everything",
+			filter: Filter{},
+			detail: mkDetail([]string{"master-abc123"}, now),
+			want:   true,
+		},
+		{
+			name:   "include tag matches",
+			filter: Filter{IncludeTag: regexp.MustCompile(`^master-`)},
+			detail: mkDetail([]string{"master-abc123"}, now),
+			want:   true,
+		},
+		{
+			name:   "include tag does not match",
+			filter: Filter{IncludeTag: regexp.MustCompile(`^release-`)},
+			detail: mkDetail([]string{"master-abc123"}, now),
+			want:   false,
+		},
+		{
+			name:   "exclude tag matches is dropped",
+			filter: Filter{ExcludeTag: regexp.MustCompile(`^latest$`)},
+			detail: mkDetail([]string{"latest"}, now),
+			want:   false,
+		},
+		{
+			name:   "max age drops stale image",
+			filter: Filter{MaxAge: time.Hour},
+			detail: mkDetail([]string{"old"}, now.Add(-2*time.Hour)),
+			want:   false,
+		},
+		{
+			name:   "max age keeps recent image",
+			filter: Filter{MaxAge: time.Hour},
+			detail: mkDetail([]string{"new"}, now.Add(-time.Minute)),
+			want:   true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.filter.matches(c.detail); got != c.want {
+				t.Errorf("matches() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package ecr
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/nearmap/cvmanager/stats"
+	"github.com/pkg/errors"
+)
+
+// maxBatchDeleteImageIDs is the maximum number of image identifiers
+// BatchDeleteImage will accept in a single call.
+const maxBatchDeleteImageIDs = 100
+
+// Retainer provides the capability of pruning old images from an ECR
+// repository once a rollout has completed, so that a repository does not
+// grow unbounded with every build. Images are kept based on recency and on
+// a caller-supplied set of protected tags (e.g. the tag currently live in
+// an environment) that must never be removed regardless of age.
+type Retainer interface {
+	// Clean removes all images in the repository identified by ecrARN except
+	// the keepN most-recently pushed images and any image carrying one of the
+	// protected tags.
+	Clean(ecrARN string, keepN int, protected ...string) error
+}
+
+type retainer struct {
+	sess  *session.Session
+	stats stats.Stats
+}
+
+func NewRetainer(sess *session.Session, stats stats.Stats) *retainer {
+	return &retainer{
+		sess:  sess,
+		stats: stats,
+	}
+}
+
+func (r *retainer) Clean(ecrARN string, keepN int, protected ...string) error {
+	repoName, accountID, region, err := NameAccountRegionFromARN(ecrARN)
+	if err != nil {
+		return errors.Wrap(err, "failed to read ECR repository ARN")
+	}
+
+	ecrClient := ecr.New(r.sess, aws.NewConfig().WithRegion(region))
+
+	return pruneImages(ecrClient, r.stats, accountID, repoName, keepN, protected)
+}
+
+// pruneImages removes all images in the repository identified by accountID
+// and repoName except the keepN most-recently pushed images and any image
+// carrying one of the protected tags. It is shared by retainer.Clean and by
+// tagger.Add's post-rollout cleanup so both go through the same pagination,
+// sort and chunked-delete logic.
+func pruneImages(ecrClient *ecr.ECR, repoStats stats.Stats, accountID, repoName string, keepN int, protected []string) error {
+	protectedTags := make(map[string]bool, len(protected))
+	for _, tag := range protected {
+		protectedTags[tag] = true
+	}
+
+	var details []*ecr.ImageDetail
+	descErr := ecrClient.DescribeImagesPages(&ecr.DescribeImagesInput{
+		RegistryId:     aws.String(accountID),
+		RepositoryName: aws.String(repoName),
+	}, func(page *ecr.DescribeImagesOutput, lastPage bool) bool {
+		details = append(details, page.ImageDetails...)
+		return true
+	})
+	if descErr != nil {
+		repoStats.IncCount(fmt.Sprintf("ecr.cleanup.%s.failure", repoName))
+		return errors.Wrap(descErr, fmt.Sprintf("failed to describe images of repository %s", repoName))
+	}
+
+	sort.Slice(details, func(i, j int) bool {
+		return aws.TimeValue(details[i].ImagePushedAt).After(aws.TimeValue(details[j].ImagePushedAt))
+	})
+
+	var toDelete []*ecr.ImageIdentifier
+	for i, detail := range details {
+		if i < keepN {
+			continue
+		}
+		if imageHasAnyTag(detail, protectedTags) {
+			continue
+		}
+		toDelete = append(toDelete, &ecr.ImageIdentifier{
+			ImageDigest: detail.ImageDigest,
+		})
+	}
+
+	for _, chunk := range chunkImageIDs(toDelete, maxBatchDeleteImageIDs) {
+		delRes, err := ecrClient.BatchDeleteImage(&ecr.BatchDeleteImageInput{
+			ImageIds:       chunk,
+			RegistryId:     aws.String(accountID),
+			RepositoryName: aws.String(repoName),
+		})
+		if err != nil {
+			repoStats.IncCount(fmt.Sprintf("ecr.cleanup.%s.failure", repoName))
+			return errors.Wrap(err, fmt.Sprintf("failed to batch delete images of repository %s", repoName))
+		}
+		for range delRes.ImageIds {
+			repoStats.IncCount(fmt.Sprintf("ecr.cleanup.%s.deleted", repoName))
+		}
+	}
+
+	return nil
+}
+
+// imageHasAnyTag reports whether detail carries any tag present in protected.
+func imageHasAnyTag(detail *ecr.ImageDetail, protected map[string]bool) bool {
+	for _, tag := range detail.ImageTags {
+		if protected[aws.StringValue(tag)] {
+			return true
+		}
+	}
+	return false
+}
+
+// chunkImageIDs splits ids into slices of at most size entries, matching the
+// AWS BatchDeleteImage limit on the number of image identifiers per call.
+func chunkImageIDs(ids []*ecr.ImageIdentifier, size int) [][]*ecr.ImageIdentifier {
+	var chunks [][]*ecr.ImageIdentifier
+	for size < len(ids) {
+		ids, chunks = ids[size:], append(chunks, ids[0:size:size])
+	}
+	if len(ids) > 0 {
+		chunks = append(chunks, ids)
+	}
+	return chunks
+}
@@ -0,0 +1,64 @@
+package ecr
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecr"
+)
+
+func TestChunkImageIDs(t *testing.T) {
+	mkIDs := func(n int) []*ecr.ImageIdentifier {
+		ids := make([]*ecr.ImageIdentifier, n)
+		for i := range ids {
+			ids[i] = &ecr.ImageIdentifier{ImageDigest: aws.String("sha256:x")}
+		}
+		return ids
+	}
+
+	cases := []struct {
+		name       string
+		n          int
+		size       int
+		wantChunks int
+		wantLast   int
+	}{
+		{"empty", 0, 100, 0, 0},
+		{"single chunk", 50, 100, 1, 50},
+		{"exact multiple", 200, 100, 2, 100},
+		{"remainder", 250, 100, 3, 50},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			chunks := chunkImageIDs(mkIDs(c.n), c.size)
+			if len(chunks) != c.wantChunks {
+				t.Fatalf("got %d chunks, want %d", len(chunks), c.wantChunks)
+			}
+			if c.wantChunks > 0 && len(chunks[len(chunks)-1]) != c.wantLast {
+				t.Fatalf("last chunk has %d ids, want %d", len(chunks[len(chunks)-1]), c.wantLast)
+			}
+			for _, chunk := range chunks {
+				if len(chunk) > c.size {
+					t.Fatalf("chunk of size %d exceeds max %d", len(chunk), c.size)
+				}
+			}
+		})
+	}
+}
+
+func TestImageHasAnyTag(t *testing.T) {
+	detail := &ecr.ImageDetail{
+		ImageTags: aws.StringSlice([]string{"latest", "v1"}),
+	}
+
+	if !imageHasAnyTag(detail, map[string]bool{"v1": true}) {
+		t.Error("expected match on v1")
+	}
+	if imageHasAnyTag(detail, map[string]bool{"prod": true}) {
+		t.Error("expected no match on prod")
+	}
+	if imageHasAnyTag(detail, nil) {
+		t.Error("expected no match against an empty protected set")
+	}
+}
@@ -0,0 +1,154 @@
+package ecr
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/nearmap/cvmanager/stats"
+	"github.com/pkg/errors"
+)
+
+// scanPollInterval is the initial delay between scan status polls. Each
+// subsequent poll backs off by scanPollBackoffFactor up to scanPollMaxInterval.
+const (
+	scanPollInterval      = 2 * time.Second
+	scanPollMaxInterval   = 30 * time.Second
+	scanPollBackoffFactor = 2
+	// scanPollTimeout bounds how long Scan will wait for a scan to reach
+	// COMPLETE/FAILED before giving up, so a stuck or throttled scan cannot
+	// wedge a rollout indefinitely.
+	scanPollTimeout = 5 * time.Minute
+)
+
+// FindingSummary is a count of ECR image scan findings by severity for a
+// single image version.
+type FindingSummary struct {
+	Critical int
+	High     int
+	Medium   int
+	Low      int
+	Other    int
+}
+
+// Exceeds reports whether the summary has more than the allowed number of
+// critical or high severity findings.
+func (f FindingSummary) Exceeds(maxCritical, maxHigh int) bool {
+	return f.Critical > maxCritical || f.High > maxHigh
+}
+
+// Scanner provides the capability of retrieving ECR image scan findings so
+// that a rollout can be gated on the vulnerability status of the image being
+// promoted.
+type Scanner interface {
+	// Scan returns the scan finding summary for the image identified by
+	// version, starting a scan and waiting for it to complete if the
+	// repository does not have scan-on-push configured.
+	Scan(ecrARN string, version string) (FindingSummary, error)
+}
+
+type scanner struct {
+	sess  *session.Session
+	stats stats.Stats
+}
+
+func NewScanner(sess *session.Session, stats stats.Stats) *scanner {
+	return &scanner{
+		sess:  sess,
+		stats: stats,
+	}
+}
+
+func (s *scanner) Scan(ecrARN string, version string) (FindingSummary, error) {
+	repoName, accountID, region, err := NameAccountRegionFromARN(ecrARN)
+	if err != nil {
+		return FindingSummary{}, errors.Wrap(err, "failed to read ECR repository ARN")
+	}
+
+	ecrClient := ecr.New(s.sess, aws.NewConfig().WithRegion(region))
+
+	imageID := &ecr.ImageIdentifier{
+		ImageTag: aws.String(version),
+	}
+
+	_, err = ecrClient.StartImageScan(&ecr.StartImageScanInput{
+		ImageId:        imageID,
+		RegistryId:     aws.String(accountID),
+		RepositoryName: aws.String(repoName),
+	})
+	if err != nil {
+		// Repositories with scan_on_push already have an in-progress or
+		// completed scan for this digest; ignore the resulting conflict and
+		// fall through to polling for its findings.
+		if aerr, ok := err.(awsErrorCoder); !ok || aerr.Code() != ecr.ErrCodeLimitExceededException {
+			s.stats.IncCount(fmt.Sprintf("ecr.scan.%s.failure", repoName))
+			return FindingSummary{}, errors.Wrap(err, fmt.Sprintf("failed to start image scan for version %s", version))
+		}
+	}
+
+	deadline := time.Now().Add(scanPollTimeout)
+	interval := scanPollInterval
+	for {
+		if time.Now().After(deadline) {
+			s.stats.IncCount(fmt.Sprintf("ecr.scan.%s.failure", repoName))
+			return FindingSummary{}, errors.Errorf("timed out after %s waiting for scan of version %s to complete",
+				scanPollTimeout, version)
+		}
+
+		findReq := &ecr.DescribeImageScanFindingsInput{
+			ImageId:        imageID,
+			RegistryId:     aws.String(accountID),
+			RepositoryName: aws.String(repoName),
+		}
+
+		findRes, err := ecrClient.DescribeImageScanFindings(findReq)
+		if err != nil {
+			s.stats.IncCount(fmt.Sprintf("ecr.scan.%s.failure", repoName))
+			return FindingSummary{}, errors.Wrap(err, fmt.Sprintf("failed to describe scan findings for version %s", version))
+		}
+
+		status := aws.StringValue(findRes.ImageScanStatus.Status)
+		switch status {
+		case ecr.ScanStatusComplete:
+			return summarizeFindings(findRes), nil
+		case ecr.ScanStatusFailed:
+			s.stats.IncCount(fmt.Sprintf("ecr.scan.%s.failure", repoName))
+			return FindingSummary{}, errors.Errorf("image scan failed for version %s: %s",
+				version, aws.StringValue(findRes.ImageScanStatus.Description))
+		}
+
+		time.Sleep(interval)
+		interval *= scanPollBackoffFactor
+		if interval > scanPollMaxInterval {
+			interval = scanPollMaxInterval
+		}
+	}
+}
+
+// awsErrorCoder is the subset of awserr.Error used to distinguish a
+// conflicting in-progress scan from a genuine StartImageScan failure.
+type awsErrorCoder interface {
+	Code() string
+}
+
+func summarizeFindings(res *ecr.DescribeImageScanFindingsOutput) FindingSummary {
+	var summary FindingSummary
+	for severity, count := range res.ImageScanFindings.FindingSeverityCounts {
+		n := int(aws.Int64Value(count))
+		switch severity {
+		case ecr.FindingSeverityCritical:
+			summary.Critical += n
+		case ecr.FindingSeverityHigh:
+			summary.High += n
+		case ecr.FindingSeverityMedium:
+			summary.Medium += n
+		case ecr.FindingSeverityLow:
+			summary.Low += n
+		default:
+			summary.Other += n
+		}
+	}
+	return summary
+}
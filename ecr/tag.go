@@ -2,8 +2,10 @@ package ecr
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ecr"
 	"github.com/nearmap/cvmanager/stats"
@@ -27,23 +29,96 @@ type Tagger interface {
 type tagger struct {
 	sess  *session.Session
 	stats stats.Stats
+
+	mu      sync.Mutex
+	clients map[string]*ecr.ECR
+
+	// retainKeepN and retainExclude, when retainKeepN is positive, drive an
+	// automatic cleanup pass after a successful Add: all but the retainKeepN
+	// most-recently pushed images are pruned, except those carrying a tag in
+	// retainExclude or one of the tags just applied by Add itself.
+	retainKeepN   int
+	retainExclude []string
+
+	// regionOverride, if non-empty, replaces the region parsed from every ARN
+	// or registry URI passed to this Tagger, so that regions not yet known to
+	// the vendored aws-sdk-go (e.g. a newly launched region) can still be
+	// targeted. See ParseECRRef.
+	regionOverride string
 }
 
 func NewTagger(sess *session.Session, stats stats.Stats) *tagger {
 
 	return &tagger{
-		sess:  sess,
-		stats: stats,
+		sess:    sess,
+		stats:   stats,
+		clients: make(map[string]*ecr.ECR),
 	}
 }
 
+// NewTaggerWithRetention returns a Tagger that, after every successful Add,
+// prunes the backing ECR repository down to keepN images by push time,
+// always preserving images tagged with excludeTags or with any tag just
+// applied by Add.
+func NewTaggerWithRetention(sess *session.Session, stats stats.Stats, keepN int, excludeTags []string) *tagger {
+	t := NewTagger(sess, stats)
+	t.retainKeepN = keepN
+	t.retainExclude = excludeTags
+	return t
+}
+
+// NewTaggerWithRegion returns a Tagger that resolves every ARN or registry
+// URI passed to it using region in place of whatever region the reference
+// itself names, for targeting regions the vendored aws-sdk-go doesn't yet
+// know about.
+func NewTaggerWithRegion(sess *session.Session, stats stats.Stats, region string) *tagger {
+	t := NewTagger(sess, stats)
+	t.regionOverride = region
+	return t
+}
+
+// resolveRef parses ecrARN, applying t.regionOverride if one is configured.
+func (t *tagger) resolveRef(ecrARN string) (repoName string, accountID string, region string, err error) {
+	ref, err := ParseECRRef(ecrARN, t.regionOverride)
+	if err != nil {
+		return "", "", "", err
+	}
+	return ref.Repo, ref.Account, ref.Region, nil
+}
+
+// clientFor returns the cached ECR client for region, creating and caching
+// one if this is the first call for that region.
+func (t *tagger) clientFor(region string) *ecr.ECR {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if client, ok := t.clients[region]; ok {
+		return client
+	}
+
+	client := ecr.New(t.sess, aws.NewConfig().WithRegion(region))
+	t.clients[region] = client
+	return client
+}
+
 func (t *tagger) Add(ecrARN string, version string, tags ...string) error {
-	repoName, accountID, region, err := NameAccountRegionFromARN(ecrARN)
+	repoName, accountID, region, err := t.resolveRef(ecrARN)
 	if err != nil {
 		return errors.Wrap(err, "failed to read ECR repository ARN")
 	}
 
-	ecrClient := ecr.New(t.sess, aws.NewConfig().WithRegion(region))
+	ecrClient := t.clientFor(region)
+
+	descRepoRes, err := ecrClient.DescribeRepositories(&ecr.DescribeRepositoriesInput{
+		RegistryId:      aws.String(accountID),
+		RepositoryNames: []*string{aws.String(repoName)},
+	})
+	if err != nil {
+		t.stats.IncCount(fmt.Sprintf("ecr.descrepo.%s.failure", repoName))
+		return errors.Wrap(err, fmt.Sprintf("failed to describe repository %s", repoName))
+	}
+	immutable := len(descRepoRes.Repositories) == 1 &&
+		aws.StringValue(descRepoRes.Repositories[0].ImageTagMutability) == ecr.ImageTagMutabilityImmutable
 
 	for _, tag := range tags {
 		fmt.Printf("Tags are %s \n", tags)
@@ -73,23 +148,59 @@ func (t *tagger) Add(ecrARN string, version string, tags ...string) error {
 
 			_, err = ecrClient.PutImage(putReq)
 			if err != nil {
+				if aerr, ok := err.(awserr.Error); ok && aerr.Code() == ecr.ErrCodeImageAlreadyExistsException &&
+					t.isRedundantTag(ecrClient, accountID, repoName, tag, img) {
+					continue
+				}
 				t.stats.IncCount(fmt.Sprintf("ecr.putimage.%s.failure", repoName))
+				if immutable {
+					return &ErrImmutableRepository{Repository: repoName}
+				}
 				return errors.Wrap(err, fmt.Sprintf("failed to add tag %s to image manifest %s",
 					tag, aws.StringValue(img.ImageManifest)))
 			}
 
 		}
 	}
+
+	if t.retainKeepN > 0 {
+		protected := append(append([]string{}, t.retainExclude...), tags...)
+		if err := pruneImages(ecrClient, t.stats, accountID, repoName, t.retainKeepN, protected); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("failed to prune old images of repository %s", repoName))
+		}
+	}
+
 	return nil
 }
 
+// isRedundantTag reports whether a PutImage ImageAlreadyExistsException for
+// tag can be treated as a no-op success, i.e. the tag already points at the
+// same digest img would have been tagged with, making a re-run of a rollout
+// safe to repeat.
+func (t *tagger) isRedundantTag(ecrClient *ecr.ECR, accountID, repoName, tag string, img *ecr.Image) bool {
+	existing, err := ecrClient.BatchGetImage(&ecr.BatchGetImageInput{
+		ImageIds: []*ecr.ImageIdentifier{
+			{
+				ImageTag: aws.String(tag),
+			},
+		},
+		RegistryId:     aws.String(accountID),
+		RepositoryName: aws.String(repoName),
+	})
+	if err != nil || len(existing.Images) != 1 {
+		return false
+	}
+
+	return aws.StringValue(existing.Images[0].ImageId.ImageDigest) == aws.StringValue(img.ImageId.ImageDigest)
+}
+
 func (t *tagger) Remove(ecrARN string, tags ...string) error {
-	repoName, accountID, region, err := NameAccountRegionFromARN(ecrARN)
+	repoName, accountID, region, err := t.resolveRef(ecrARN)
 	if err != nil {
 		return errors.Wrap(err, "failed to read ECR repository ARN")
 	}
 
-	ecrClient := ecr.New(t.sess, aws.NewConfig().WithRegion(region))
+	ecrClient := t.clientFor(region)
 
 	for _, tag := range tags {
 		getReq := &ecr.BatchGetImageInput{
@@ -134,12 +245,12 @@ func (t *tagger) Remove(ecrARN string, tags ...string) error {
 }
 
 func (t *tagger) Get(ecrARN string, version string) ([]string, error) {
-	repoName, accountID, region, err := NameAccountRegionFromARN(ecrARN)
+	repoName, accountID, region, err := t.resolveRef(ecrARN)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to read ECR repository ARN")
 	}
 
-	ecrClient := ecr.New(t.sess, aws.NewConfig().WithRegion(region))
+	ecrClient := t.clientFor(region)
 
 	getReq := &ecr.DescribeImagesInput{
 		ImageIds: []*ecr.ImageIdentifier{
@@ -160,6 +271,9 @@ func (t *tagger) Get(ecrARN string, version string) ([]string, error) {
 	if len(getRes.ImageDetails) > 1 {
 		return nil, errors.New("More than one image with version tag was found ... bad state!")
 	}
+	if len(getRes.ImageDetails) == 0 {
+		return nil, errors.Errorf("no image with version tag %s was found", version)
+	}
 
 	return aws.StringValueSlice(getRes.ImageDetails[0].ImageTags), nil
 
@@ -0,0 +1,174 @@
+package registry
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// bearerTransport implements the Docker Registry v2 token authentication
+// flow (https://docs.docker.com/registry/spec/auth/token/): a request that
+// comes back 401 with a "WWW-Authenticate: Bearer ..." challenge is retried
+// once with a bearer token fetched from the challenge's realm, using
+// username/password for the initial token request if configured. Tokens are
+// cached per repository (see repoScope) and sent with later requests against
+// it; a cached token that is refused with a new challenge is replaced by a
+// freshly fetched one.
+type bearerTransport struct {
+	base     http.RoundTripper
+	username string
+	password string
+
+	mu     sync.Mutex
+	tokens map[string]string
+}
+
+func newBearerTransport(username, password string) *bearerTransport {
+	return &bearerTransport{
+		base:     http.DefaultTransport,
+		username: username,
+		password: password,
+		tokens:   make(map[string]string),
+	}
+}
+
+func (b *bearerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var resp *http.Response
+	var err error
+	if token, ok := b.cachedToken(repoScope(req.URL.Path)); ok {
+		authed := req.Clone(req.Context())
+		authed.Header.Set("Authorization", "Bearer "+token)
+		resp, err = b.base.RoundTrip(authed)
+	} else {
+		resp, err = b.base.RoundTrip(req)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	challenge := resp.Header.Get("WWW-Authenticate")
+	realm, service, scope, ok := parseBearerChallenge(challenge)
+	if !ok {
+		return resp, nil
+	}
+
+	token, err := b.fetchToken(realm, service, scope)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch registry auth token")
+	}
+	b.cacheToken(repoScope(req.URL.Path), token)
+
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to rewind request body for authenticated retry")
+		}
+		req.Body = body
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	return b.base.RoundTrip(req)
+}
+
+func (b *bearerTransport) cachedToken(key string) (string, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	token, ok := b.tokens[key]
+	return token, ok
+}
+
+func (b *bearerTransport) cacheToken(key, token string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens[key] = token
+}
+
+func (b *bearerTransport) fetchToken(realm, service, scope string) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, realm, nil)
+	if err != nil {
+		return "", err
+	}
+
+	q := req.URL.Query()
+	if service != "" {
+		q.Set("service", service)
+	}
+	if scope != "" {
+		q.Set("scope", scope)
+	}
+	req.URL.RawQuery = q.Encode()
+
+	if b.username != "" {
+		req.SetBasicAuth(b.username, b.password)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("token endpoint %s returned status %s", realm, resp.Status)
+	}
+
+	var out struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", err
+	}
+	if out.Token != "" {
+		return out.Token, nil
+	}
+	return out.AccessToken, nil
+}
+
+// repoPathPattern extracts the repository path from a Distribution Spec v2
+// request path (e.g. "/v2/proj/app/manifests/v1" -> "proj/app"), so that
+// tag/digest/list requests against the same repository share one cached
+// token instead of each minting its own.
+var repoPathPattern = regexp.MustCompile(`^/v2/(.+)/(manifests|tags|blobs)/`)
+
+// repoScope returns the cache key for the bearer token authorizing requests
+// against path, derived from the repository rather than the full request URL
+// so that it matches across tags and digests of the same repo.
+func repoScope(path string) string {
+	if m := repoPathPattern.FindStringSubmatch(path); m != nil {
+		return m[1]
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(path, "/v2/"), "/tags/list")
+}
+
+// bearerChallengePattern matches the quoted key="value" pairs of a
+// WWW-Authenticate: Bearer challenge header.
+var bearerChallengePattern = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+func parseBearerChallenge(header string) (realm, service, scope string, ok bool) {
+	const prefix = "Bearer "
+	if len(header) < len(prefix) || header[:len(prefix)] != prefix {
+		return "", "", "", false
+	}
+
+	for _, m := range bearerChallengePattern.FindAllStringSubmatch(header, -1) {
+		switch m[1] {
+		case "realm":
+			realm = m[2]
+		case "service":
+			service = m[2]
+		case "scope":
+			scope = m[2]
+		}
+	}
+
+	return realm, service, scope, realm != ""
+}
@@ -0,0 +1,23 @@
+package registry
+
+import (
+	"net/http"
+	"os"
+
+	"github.com/nearmap/cvmanager/stats"
+)
+
+// NewDockerHub returns a Registry backed by Docker Hub, speaking the OCI
+// Distribution Spec directly. Credentials are read from DOCKERHUB_USERNAME
+// and DOCKERHUB_PASSWORD; if unset, requests are made anonymously, which
+// only succeeds against public images.
+func NewDockerHub(stats stats.Stats) Registry {
+	return &ociRegistry{
+		name:   "dockerhub",
+		scheme: "https",
+		host:   "registry-1.docker.io",
+		client: &http.Client{Transport: newBearerTransport(
+			os.Getenv("DOCKERHUB_USERNAME"), os.Getenv("DOCKERHUB_PASSWORD"))},
+		stats: stats,
+	}
+}
@@ -0,0 +1,14 @@
+package registry
+
+import (
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/nearmap/cvmanager/ecr"
+	"github.com/nearmap/cvmanager/stats"
+)
+
+// NewECR returns a Registry backed by Amazon ECR. repo arguments passed to
+// the returned Registry must be ECR repository ARNs or ECR registry URIs, as
+// accepted by ecr.ParseECRRef.
+func NewECR(sess *session.Session, stats stats.Stats) Registry {
+	return ecr.NewTagger(sess, stats)
+}
@@ -0,0 +1,36 @@
+package registry
+
+import (
+	"net/http"
+	"os"
+
+	"github.com/nearmap/cvmanager/stats"
+)
+
+// gcrKeyFileEnv names the environment variable holding the path to a GCR
+// service account JSON key, mirroring GOOGLE_APPLICATION_CREDENTIALS. GCR
+// accepts this key file verbatim as the password for the fixed username
+// "_json_key" in the standard Docker Registry v2 token exchange.
+const gcrKeyFileEnv = "GCR_KEY_FILE"
+
+// NewGCR returns a Registry backed by Google Container Registry (or
+// Artifact Registry's GCR-compatible host), speaking the OCI Distribution
+// Spec directly. If GCR_KEY_FILE names a readable service account key, it
+// is used to authenticate; otherwise requests are made anonymously, which
+// only succeeds against public images.
+func NewGCR(stats stats.Stats) Registry {
+	var username, password string
+	if keyFile := os.Getenv(gcrKeyFileEnv); keyFile != "" {
+		if key, err := os.ReadFile(keyFile); err == nil {
+			username, password = "_json_key", string(key)
+		}
+	}
+
+	return &ociRegistry{
+		name:   "gcr",
+		scheme: "https",
+		host:   "gcr.io",
+		client: &http.Client{Transport: newBearerTransport(username, password)},
+		stats:  stats,
+	}
+}
@@ -0,0 +1,33 @@
+package registry
+
+import (
+	"net/http"
+	"os"
+
+	"github.com/nearmap/cvmanager/stats"
+)
+
+// NewGHCR returns a Registry backed by the GitHub Container Registry,
+// speaking the OCI Distribution Spec directly. A GHCR_TOKEN personal access
+// token (or GITHUB_TOKEN, when running inside GitHub Actions) with
+// write:packages scope is used to authenticate; if neither is set, requests
+// are made anonymously, which only succeeds against public images.
+func NewGHCR(stats stats.Stats) Registry {
+	token := os.Getenv("GHCR_TOKEN")
+	if token == "" {
+		token = os.Getenv("GITHUB_TOKEN")
+	}
+
+	var username string
+	if token != "" {
+		username = "token"
+	}
+
+	return &ociRegistry{
+		name:   "ghcr",
+		scheme: "https",
+		host:   "ghcr.io",
+		client: &http.Client{Transport: newBearerTransport(username, token)},
+		stats:  stats,
+	}
+}
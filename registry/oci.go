@@ -0,0 +1,196 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/nearmap/cvmanager/stats"
+	"github.com/pkg/errors"
+)
+
+// manifestMediaTypes are the manifest media types requested when fetching an
+// image manifest, in preference order.
+var manifestMediaTypes = []string{
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+}
+
+// ociRegistry is a Registry implementation speaking the OCI Distribution
+// Spec (https://github.com/opencontainers/distribution-spec): manifests are
+// re-tagged with `PUT /v2/<name>/manifests/<tag>` and removed with
+// `DELETE /v2/<name>/manifests/<digest>`. scheme/host identify the registry
+// endpoint and name is the backend's human-readable name, used in stats
+// counters to match the convention in ecr/tag.go.
+type ociRegistry struct {
+	name   string
+	scheme string
+	host   string
+	client *http.Client
+	stats  stats.Stats
+}
+
+func (o *ociRegistry) manifestURL(repo, reference string) string {
+	return fmt.Sprintf("%s://%s/v2/%s/manifests/%s", o.scheme, o.host, repo, reference)
+}
+
+func (o *ociRegistry) Add(repo string, version string, tags ...string) error {
+	manifest, contentType, err := o.getManifest(repo, version)
+	if err != nil {
+		o.stats.IncCount(fmt.Sprintf("%s.get.%s.failure", o.name, repo))
+		return errors.Wrap(err, fmt.Sprintf("failed to get manifest for version %s", version))
+	}
+
+	for _, tag := range tags {
+		req, err := http.NewRequest(http.MethodPut, o.manifestURL(repo, tag), bytes.NewReader(manifest))
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("failed to build request to tag %s", tag))
+		}
+		req.Header.Set("Content-Type", contentType)
+
+		resp, err := o.client.Do(req)
+		if err != nil {
+			o.stats.IncCount(fmt.Sprintf("%s.put.%s.failure", o.name, repo))
+			return errors.Wrap(err, fmt.Sprintf("failed to put manifest for tag %s", tag))
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusCreated {
+			o.stats.IncCount(fmt.Sprintf("%s.put.%s.failure", o.name, repo))
+			return errors.Errorf("failed to add tag %s to image manifest: unexpected status %s", tag, resp.Status)
+		}
+	}
+	return nil
+}
+
+func (o *ociRegistry) Remove(repo string, tags ...string) error {
+	for _, tag := range tags {
+		digest, err := o.headManifestDigest(repo, tag)
+		if err != nil {
+			o.stats.IncCount(fmt.Sprintf("%s.head.%s.failure", o.name, repo))
+			return errors.Wrap(err, fmt.Sprintf("failed to resolve digest of tag %s", tag))
+		}
+
+		req, err := http.NewRequest(http.MethodDelete, o.manifestURL(repo, digest), nil)
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("failed to build request to remove tag %s", tag))
+		}
+
+		resp, err := o.client.Do(req)
+		if err != nil {
+			o.stats.IncCount(fmt.Sprintf("%s.delete.%s.failure", o.name, repo))
+			return errors.Wrap(err, fmt.Sprintf("failed to delete manifest for tag %s", tag))
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusAccepted {
+			o.stats.IncCount(fmt.Sprintf("%s.delete.%s.failure", o.name, repo))
+			return errors.Errorf("failed to remove tag %s: unexpected status %s", tag, resp.Status)
+		}
+	}
+	return nil
+}
+
+func (o *ociRegistry) Get(repo string, version string) ([]string, error) {
+	digest, err := o.headManifestDigest(repo, version)
+	if err != nil {
+		o.stats.IncCount(fmt.Sprintf("%s.get.%s.failure", o.name, repo))
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to resolve digest of version %s", version))
+	}
+
+	tagsResp, err := o.client.Get(fmt.Sprintf("%s://%s/v2/%s/tags/list", o.scheme, o.host, repo))
+	if err != nil {
+		o.stats.IncCount(fmt.Sprintf("%s.get.%s.failure", o.name, repo))
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to list tags of repository %s", repo))
+	}
+	defer tagsResp.Body.Close()
+
+	allTags, err := decodeTagList(tagsResp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode tags list response")
+	}
+
+	var matching []string
+	for _, tag := range allTags {
+		tagDigest, err := o.headManifestDigest(repo, tag)
+		if err != nil {
+			continue
+		}
+		if tagDigest == digest {
+			matching = append(matching, tag)
+		}
+	}
+
+	return matching, nil
+}
+
+// headManifestDigest resolves reference (a tag or digest) to its manifest
+// digest via the Docker-Content-Digest response header, as returned by a
+// HEAD request against the manifest endpoint.
+func (o *ociRegistry) headManifestDigest(repo, reference string) (string, error) {
+	req, err := http.NewRequest(http.MethodHead, o.manifestURL(repo, reference), nil)
+	if err != nil {
+		return "", err
+	}
+	for _, mt := range manifestMediaTypes {
+		req.Header.Add("Accept", mt)
+	}
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("unexpected status resolving manifest digest: %s", resp.Status)
+	}
+
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if digest == "" {
+		return "", errors.New("registry did not return a Docker-Content-Digest header")
+	}
+	return digest, nil
+}
+
+// getManifest fetches the raw manifest body for reference along with its
+// content type, so the body can be PUT unmodified to one or more new tags.
+func (o *ociRegistry) getManifest(repo, reference string) (body []byte, contentType string, err error) {
+	req, err := http.NewRequest(http.MethodGet, o.manifestURL(repo, reference), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, mt := range manifestMediaTypes {
+		req.Header.Add("Accept", mt)
+	}
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", errors.Errorf("unexpected status fetching manifest: %s", resp.Status)
+	}
+
+	body, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return body, resp.Header.Get("Content-Type"), nil
+}
+
+// decodeTagList decodes the body of a GET /v2/<name>/tags/list response.
+func decodeTagList(r io.Reader) ([]string, error) {
+	var out struct {
+		Tags []string `json:"tags"`
+	}
+	if err := json.NewDecoder(r).Decode(&out); err != nil {
+		return nil, err
+	}
+	return out.Tags, nil
+}
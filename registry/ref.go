@@ -0,0 +1,31 @@
+package registry
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// SplitDomain splits an image reference into its registry domain and the
+// remaining repo[:tag] portion, mirroring the domain/repo split used by the
+// docker/distribution reference package: a reference has a domain component
+// only when its first path segment contains a "." or ":", or is "localhost".
+// References with no identifiable domain (e.g. "nearmap/app") are treated as
+// Docker Hub and return an empty domain.
+func SplitDomain(ref string) (domain string, remainder string, err error) {
+	if ref == "" {
+		return "", "", errors.New("image reference must not be empty")
+	}
+
+	i := strings.IndexByte(ref, '/')
+	if i == -1 {
+		return "", ref, nil
+	}
+
+	first := ref[:i]
+	if !strings.ContainsAny(first, ".:") && first != "localhost" {
+		return "", ref, nil
+	}
+
+	return first, ref[i+1:], nil
+}
@@ -0,0 +1,46 @@
+package registry
+
+import "testing"
+
+func TestSplitDomain(t *testing.T) {
+	cases := []struct {
+		ref        string
+		wantDomain string
+		wantRest   string
+		wantErr    bool
+	}{
+		{ref: "", wantErr: true},
+		{ref: "nearmap/app", wantDomain: "", wantRest: "nearmap/app"},
+		{ref: "app", wantDomain: "", wantRest: "app"},
+		{ref: "gcr.io/proj/app", wantDomain: "gcr.io", wantRest: "proj/app"},
+		{ref: "ghcr.io/nearmap/app:latest", wantDomain: "ghcr.io", wantRest: "nearmap/app:latest"},
+		{ref: "localhost/app", wantDomain: "localhost", wantRest: "app"},
+		{ref: "localhost:5000/app", wantDomain: "localhost:5000", wantRest: "app"},
+		{
+			ref:        "123456789012.dkr.ecr.us-east-1.amazonaws.com/my-repo",
+			wantDomain: "123456789012.dkr.ecr.us-east-1.amazonaws.com",
+			wantRest:   "my-repo",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.ref, func(t *testing.T) {
+			domain, rest, err := SplitDomain(c.ref)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("expected error for ref %q", c.ref)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if domain != c.wantDomain {
+				t.Errorf("domain = %q, want %q", domain, c.wantDomain)
+			}
+			if rest != c.wantRest {
+				t.Errorf("remainder = %q, want %q", rest, c.wantRest)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+// Package registry provides a registry-agnostic interface for adding,
+// removing and inspecting environment tags on container images, so that
+// cvmanager is not tied to ECR. Concrete backends live alongside this file:
+// ecr.go wraps the existing ecr.Tagger, while gcr.go, dockerhub.go and
+// ghcr.go speak the OCI Distribution Spec directly.
+package registry
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/nearmap/cvmanager/ecr"
+	"github.com/nearmap/cvmanager/stats"
+	"github.com/pkg/errors"
+)
+
+// Registry adds/removes/inspects tags on images hosted by some container
+// registry. repo identifies the image, e.g. "gcr.io/proj/app" or the ECR
+// repository ARN, and version identifies the immutable version tag (such as
+// a git SHA) that the mutable tags are layered on top of.
+type Registry interface {
+	// Add adds tags to the image identified by version.
+	Add(repo string, version string, tags ...string) error
+	// Remove removes tags from the repository such that no image carries them.
+	Remove(repo string, tags ...string) error
+	// Get gets the tags currently applied to the image identified by version.
+	Get(repo string, version string) ([]string, error)
+}
+
+// New selects a Registry implementation for ref, which may be a standard ECR
+// repository ARN ("arn:aws:ecr:us-east-1:123456789012:repository/my-repo"),
+// an ECR registry URI ("<account>.dkr.ecr.<region>.amazonaws.com/<repo>"),
+// a GCR/GHCR image reference ("gcr.io/<project>/<repo>", "ghcr.io/<owner>/<repo>"),
+// or a bare Docker Hub repo such as "nearmap/app".
+func New(ref string, sess *session.Session, stats stats.Stats) (Registry, error) {
+	// ECR repository ARNs don't carry a registry domain segment at all (they
+	// use "arn:aws:ecr:..." rather than "<domain>/<repo>"), so they can't be
+	// recognized by SplitDomain; try the ECR-specific parser first and only
+	// fall back to domain-splitting for the other backends.
+	if _, err := ecr.ParseECRRef(ref, ""); err == nil {
+		// ecr.Tagger parses account/region out of the full ARN/URI itself on
+		// every call, so it is bound to ref unchanged.
+		return &boundRegistry{repo: ref, backend: NewECR(sess, stats)}, nil
+	}
+
+	domain, repo, err := SplitDomain(ref)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse image reference")
+	}
+
+	var backend Registry
+	switch {
+	case domain == "gcr.io" || strings.HasSuffix(domain, ".gcr.io"):
+		backend = NewGCR(stats)
+	case domain == "ghcr.io":
+		backend = NewGHCR(stats)
+	case domain == "" || domain == "docker.io" || domain == "index.docker.io":
+		backend = NewDockerHub(stats)
+	default:
+		return nil, errors.Errorf("unsupported registry domain %q", domain)
+	}
+
+	return &boundRegistry{repo: repo, backend: backend}, nil
+}
+
+// boundRegistry binds a Registry backend to the repo path it was resolved
+// for, so that callers keep passing around the original ref (which may still
+// carry its registry domain, e.g. "gcr.io/proj/app") while the backend only
+// ever sees the domain-stripped repo it actually expects. Without this, a
+// domain such as "gcr.io" would be duplicated into every manifest URL the
+// backend builds.
+type boundRegistry struct {
+	repo    string
+	backend Registry
+}
+
+func (b *boundRegistry) Add(repo string, version string, tags ...string) error {
+	return b.backend.Add(b.repo, version, tags...)
+}
+
+func (b *boundRegistry) Remove(repo string, tags ...string) error {
+	return b.backend.Remove(b.repo, tags...)
+}
+
+func (b *boundRegistry) Get(repo string, version string) ([]string, error) {
+	return b.backend.Get(b.repo, version)
+}
@@ -0,0 +1,78 @@
+package registry
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// fakeStats is a minimal stats.Stats implementation for tests that don't
+// care about the counters themselves, only that calls to IncCount succeed.
+type fakeStats struct{}
+
+func (fakeStats) IncCount(name string) {}
+
+// TestNewOCIDispatchStripsDomain exercises New end-to-end against a fake
+// registry server: it verifies that a reference like "gcr.io/proj/app" is
+// dispatched to the GCR backend with the domain stripped from repo, so that
+// requests the backend makes hit "/v2/proj/app/manifests/..." rather than
+// duplicating the domain into the path.
+func TestNewOCIDispatchStripsDomain(t *testing.T) {
+	const manifest = `{"schemaVersion":2}`
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v2/proj/app/manifests/v1", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.Header().Set("Docker-Content-Digest", "sha256:deadbeef")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.docker.distribution.manifest.v2+json")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, manifest)
+	})
+	mux.HandleFunc("/v2/proj/app/tags/list", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"tags":[]}`)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	reg, err := New("gcr.io/proj/app", nil, fakeStats{})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	bound, ok := reg.(*boundRegistry)
+	if !ok {
+		t.Fatalf("New returned %T, want *boundRegistry", reg)
+	}
+	if bound.repo != "proj/app" {
+		t.Fatalf("bound.repo = %q, want %q (domain should have been stripped)", bound.repo, "proj/app")
+	}
+
+	oci, ok := bound.backend.(*ociRegistry)
+	if !ok {
+		t.Fatalf("bound.backend = %T, want *ociRegistry", bound.backend)
+	}
+	if oci.name != "gcr" {
+		t.Fatalf("backend name = %q, want %q", oci.name, "gcr")
+	}
+
+	srvURL, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatalf("failed to parse test server URL: %v", err)
+	}
+	oci.scheme = "http"
+	oci.host = srvURL.Host
+
+	tags, err := reg.Get("gcr.io/proj/app", "v1")
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	if len(tags) != 0 {
+		t.Errorf("Get tags = %v, want empty (tags/list handler returns no tags)", tags)
+	}
+}